@@ -34,6 +34,7 @@ import (
 	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 )
 
 // RBAC permissions used in all reconcilers. Events and Secrets.
@@ -71,22 +72,51 @@ func NewCSClusterReconciliationRunner() *CloudStackClusterReconciliationRunner {
 	// For the CloudStackCluster, the ReconciliationSubject is the CSCluster
 	// Have to do after or the setup method will overwrite the link.
 	r.CSCluster = r.ReconciliationSubject
+	r.Finalizer = infrav1.ClusterFinalizer
 
 	return r
 }
 
-// Reconcile is the method k8s will call upon a reconciliation request.
+// Reconcile is the method k8s will call upon a reconciliation request. It
+// fetches the CloudStackCluster, arranges for its status to always be
+// persisted, and dispatches to the runner's reconcileNormal or
+// reconcileDelete path based on whether it's being deleted.
 func (reconciler *CloudStackClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (retRes ctrl.Result, retErr error) {
-	return NewCSClusterReconciliationRunner().
+	r := NewCSClusterReconciliationRunner().
 		UsingBaseReconciler(reconciler.ReconcilerBase).
 		ForRequest(req).
-		WithRequestCtx(ctx).
-		RunBaseReconciliationStages()
+		WithRequestCtx(ctx)
+
+	found, err := r.GetReconciliationSubject()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !found {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.SetupPatchHelper(); err != nil {
+		return ctrl.Result{}, err
+	}
+	defer func() {
+		if r.ReconciliationSubject.GetDeletionTimestamp().IsZero() {
+			r.SetReadyCondition(retErr)
+		} else {
+			r.SetDeletingCondition()
+		}
+		if err := r.PersistReconciliationSubject(); err != nil && retErr == nil {
+			retRes, retErr = ctrl.Result{}, err
+		}
+	}()
+
+	return r.DispatchNormalOrDelete()
 }
 
 // Reconcile actually reconciles the CloudStackCluster.
 func (r *CloudStackClusterReconciliationRunner) Reconcile() (res ctrl.Result, reterr error) {
 	return r.RunReconciliationStages(
+		r.GetCAPICluster,
+		r.EnforceOwnerReferencesOnCluster,
 		r.SetFailureDomainsStatusMap,
 		r.CreateFailureDomains(r.ReconciliationSubject.Spec.FailureDomains),
 		r.GetFailureDomains(r.FailureDomains),
@@ -94,13 +124,47 @@ func (r *CloudStackClusterReconciliationRunner) Reconcile() (res ctrl.Result, re
 		r.SetReady)
 }
 
-// SetReady adds a finalizer and sets the cluster status to ready.
+// EnforceOwnerReferencesOnCluster ensures the CloudStackCluster carries an
+// ownerReference to its CAPI Cluster, self-healing it if a prior reconcile
+// flaked before persisting it. The finalizer is handled separately by
+// DispatchNormalOrDelete.
+func (r *CloudStackClusterReconciliationRunner) EnforceOwnerReferencesOnCluster() (ctrl.Result, error) {
+	return r.EnforceOwnerReferencesStage(r.CAPICluster)()
+}
+
+// SetReady sets the cluster status to ready. The finalizer itself is added by
+// DispatchNormalOrDelete before any of these stages run.
 func (r *CloudStackClusterReconciliationRunner) SetReady() (ctrl.Result, error) {
-	controllerutil.AddFinalizer(r.ReconciliationSubject, infrav1.ClusterFinalizer)
 	r.ReconciliationSubject.Status.Ready = true
 	return ctrl.Result{}, nil
 }
 
+// SetReadyCondition mirrors the outcome of this reconcile onto the
+// CloudStackCluster's Ready condition, so it's set consistently on every
+// return path, including early returns and reconcile errors, not just the
+// happy path that reaches SetReady.
+func (r *CloudStackClusterReconciliationRunner) SetReadyCondition(reconcileErr error) {
+	switch {
+	case reconcileErr != nil:
+		conditions.MarkFalse(r.ReconciliationSubject, clusterv1.ReadyCondition, "ReconcileError", clusterv1.ConditionSeverityError, "%s", reconcileErr.Error())
+	case r.ReconciliationSubject.Status.Ready:
+		conditions.MarkTrue(r.ReconciliationSubject, clusterv1.ReadyCondition)
+	default:
+		conditions.MarkFalse(r.ReconciliationSubject, clusterv1.ReadyCondition, "ReconcileInProgress", clusterv1.ConditionSeverityInfo, "")
+	}
+}
+
+// SetDeletingCondition mirrors a delete-path reconcile onto the
+// CloudStackCluster's Ready condition. ReconcileDelete never sets
+// Status.Ready, so the defer in Reconcile must not fall through to
+// SetReadyCondition here - that would re-mark Ready True from a stale
+// Status.Ready left over from the cluster's last successful reconcile,
+// even while deletion is still waiting on child FailureDomains.
+func (r *CloudStackClusterReconciliationRunner) SetDeletingCondition() {
+	r.ReconciliationSubject.Status.Ready = false
+	conditions.MarkFalse(r.ReconciliationSubject, clusterv1.ReadyCondition, "Deleting", clusterv1.ConditionSeverityInfo, "")
+}
+
 // VerifyFailureDomainCRDs verifies the FailureDomains found match against those requested.
 func (r *CloudStackClusterReconciliationRunner) VerifyFailureDomainCRDs() (ctrl.Result, error) {
 	expected := len(r.ReconciliationSubject.Spec.FailureDomains)
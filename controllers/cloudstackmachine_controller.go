@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	csCtrlrUtils "sigs.k8s.io/cluster-api-provider-cloudstack/controllers/utils"
+)
+
+// RBAC permissions for CloudStackMachine.
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachines,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachines/status,verbs=create;get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=cloudstackmachines/finalizers,verbs=update
+
+// CloudStackMachineReconciliationRunner is a ReconciliationRunner with
+// extensions specific to CloudStackMachines. The runner does the actual
+// reconciliation.
+//
+// This is a minimal runner: it exists so that controllers/utils.DrainNode
+// has a real caller on the machine delete path. It does not implement
+// normal-path reconciliation (CloudStack VM create, bootstrap data, provider
+// ID) and makes no CloudStack VM Destroy call.
+type CloudStackMachineReconciliationRunner struct {
+	*csCtrlrUtils.ReconciliationRunner
+	ReconciliationSubject *infrav1.CloudStackMachine
+}
+
+// CloudStackMachineReconciler is the k8s controller manager's interface to reconcile a CloudStackMachine.
+// This is primarily to adapt to k8s.
+type CloudStackMachineReconciler struct {
+	csCtrlrUtils.ReconcilerBase
+}
+
+// NewCSMachineReconciliationRunner initializes a new CloudStackMachine reconciliation runner with concrete types and initialized member fields.
+func NewCSMachineReconciliationRunner() *CloudStackMachineReconciliationRunner {
+	r := &CloudStackMachineReconciliationRunner{ReconciliationSubject: &infrav1.CloudStackMachine{}}
+	r.ReconciliationRunner = csCtrlrUtils.NewRunner(r, r.ReconciliationSubject, "CloudStackMachine")
+	r.Finalizer = infrav1.MachineFinalizer
+
+	return r
+}
+
+// Reconcile is the method k8s will call upon a reconciliation request. It
+// fetches the CloudStackMachine, arranges for it to always be persisted, and
+// dispatches to the runner's reconcileNormal or reconcileDelete path based on
+// whether it's being deleted.
+func (reconciler *CloudStackMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (retRes ctrl.Result, retErr error) {
+	r := NewCSMachineReconciliationRunner().
+		UsingBaseReconciler(reconciler.ReconcilerBase).
+		ForRequest(req).
+		WithRequestCtx(ctx)
+
+	found, err := r.GetReconciliationSubject()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !found {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.SetupPatchHelper(); err != nil {
+		return ctrl.Result{}, err
+	}
+	defer func() {
+		if err := r.PersistReconciliationSubject(); err != nil && retErr == nil {
+			retRes, retErr = ctrl.Result{}, err
+		}
+	}()
+
+	return r.DispatchNormalOrDelete()
+}
+
+// Reconcile actually reconciles the CloudStackMachine. The finalizer itself
+// is added by DispatchNormalOrDelete before this stage list runs.
+func (r *CloudStackMachineReconciliationRunner) Reconcile() (ctrl.Result, error) {
+	return r.RunReconciliationStages(r.GetCAPICluster)
+}
+
+// ReconcileDelete drains the workload cluster node backing this machine,
+// requeueing while DrainNode reports drain still in progress. It does not
+// destroy the CloudStack VM and does not remove infrav1.MachineFinalizer.
+func (r *CloudStackMachineReconciliationRunner) ReconcileDelete() (ctrl.Result, error) {
+	r.Log.Info("Deleting CloudStackMachine.")
+	// DrainNode needs r.CAPICluster to reach the workload cluster, and
+	// (unlike CloudStackCluster's delete path) this delete path does need
+	// it, so resolve it here rather than assuming a normal-path reconcile
+	// already ran.
+	return r.RunReconciliationStages(r.GetCAPICluster, r.DrainNode)
+}
+
+// DrainNode is a reconciliation stage wrapping csCtrlrUtils.DrainNode.
+func (r *CloudStackMachineReconciliationRunner) DrainNode() (ctrl.Result, error) {
+	return csCtrlrUtils.DrainNode(r.ReconciliationRunner, r.ReconciliationSubject, r.Recorder)
+}
+
+// SetupWithManager registers the machine reconciler with the CAPI controller manager.
+func (reconciler *CloudStackMachineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.CloudStackMachine{}).
+		Complete(reconciler)
+}
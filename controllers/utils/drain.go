@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	kubedrain "k8s.io/kubectl/pkg/drain"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/controllers/remote"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+// DrainRequeueAfter is how long to wait before checking again whether a
+// node's non-DaemonSet pods have finished evicting.
+const DrainRequeueAfter = 20 * time.Second
+
+// drainAttemptTimeout bounds how long a single drainNode call will block
+// evicting pods before giving up on this attempt and requeueing, so a drain
+// is spread across many short, requeued attempts rather than one blocking
+// call. A machine.Spec.DrainTimeout shorter than this lowers the bound for
+// each attempt; no limit is placed on the total time spent across attempts.
+const drainAttemptTimeout = 5 * time.Second
+
+// DrainingSucceededCondition reports whether the workload cluster node
+// backing a CloudStackMachine has been drained.
+const DrainingSucceededCondition clusterv1.ConditionType = "DrainingSucceeded"
+
+// DrainNode cordons and evicts the non-DaemonSet pods from the workload
+// cluster node backing machine, honoring PodDisruptionBudgets, the grace
+// period configured on the machine's spec, and its drain timeout as a cap on
+// each attempt (see drainAttemptTimeout). It runs as a stage in the
+// CloudStackMachine delete path. It returns a non-zero Result (never an error) while eviction is still in
+// progress, and only errors on a terminal failure (e.g. can't reach the
+// workload cluster). It's a no-op if the machine has no Status.NodeRef.
+//
+// r.CAPICluster must already be populated (e.g. by a prior GetCAPICluster
+// stage); DrainNode errors rather than dereferencing a nil cluster.
+//
+// It's called from CloudStackMachineReconciliationRunner.ReconcileDelete in
+// controllers/cloudstackmachine_controller.go; that delete path makes no
+// CloudStack VM Destroy call.
+func DrainNode(r *ReconciliationRunner, machine *infrav1.CloudStackMachine, recorder record.EventRecorder) (ctrl.Result, error) {
+	if machine.Status.NodeRef == nil {
+		return ctrl.Result{}, nil
+	}
+	if r.CAPICluster == nil {
+		return ctrl.Result{}, errors.New("CAPICluster not set, cannot resolve workload cluster rest config")
+	}
+
+	restConfig, err := remote.RESTConfig(r.RequestCtx, machine.Name, r.K8sClient, client.ObjectKeyFromObject(r.CAPICluster))
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "getting workload cluster rest config")
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "building workload cluster clientset")
+	}
+
+	return drainNode(r.RequestCtx, clientset, machine, recorder)
+}
+
+// drainNode holds DrainNode's actual logic against an already-built workload
+// cluster clientset, so it can be exercised with a fake clientset in tests.
+func drainNode(ctx context.Context, clientset kubernetes.Interface, machine *infrav1.CloudStackMachine, recorder record.EventRecorder) (ctrl.Result, error) {
+	node, err := clientset.CoreV1().Nodes().Get(ctx, machine.Status.NodeRef.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		conditions.MarkTrue(machine, DrainingSucceededCondition)
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "getting node")
+	}
+
+	// NodeDrainGracePeriod and DrainTimeout are read off CloudStackMachineSpec
+	// in api/v1beta2, which isn't part of this tree's snapshot (no api/
+	// directory) and so couldn't be extended with these fields from here;
+	// they're assumed present on the real CloudStackMachineSpec this lands
+	// against, following the CAPI convention other providers use for the
+	// same two knobs.
+	gracePeriod := -1
+	if machine.Spec.NodeDrainGracePeriod != nil {
+		gracePeriod = int(machine.Spec.NodeDrainGracePeriod.Duration.Seconds())
+	}
+	attemptTimeout := drainAttemptTimeout
+	if machine.Spec.DrainTimeout != nil && machine.Spec.DrainTimeout.Duration < attemptTimeout {
+		attemptTimeout = machine.Spec.DrainTimeout.Duration
+	}
+
+	helper := &kubedrain.Helper{
+		Ctx:                 ctx,
+		Client:              clientset,
+		Force:               true,
+		IgnoreAllDaemonSets: true,
+		DeleteEmptyDirData:  true,
+		GracePeriodSeconds:  gracePeriod,
+		Timeout:             attemptTimeout,
+		OnPodDeletedOrEvicted: func(pod *corev1.Pod, usingEviction bool) {
+			recorder.Eventf(machine, corev1.EventTypeNormal, "NodeDrain", "Evicted pod %s/%s from node %s", pod.Namespace, pod.Name, node.Name)
+		},
+	}
+
+	if err := kubedrain.RunCordonOrUncordon(helper, node, true); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "cordoning node")
+	}
+
+	podDeleteList, errs := helper.GetPodsForDeletion(node.Name)
+	if errs != nil {
+		return ctrl.Result{}, errors.Wrap(errs, "listing pods for deletion")
+	}
+	if len(podDeleteList.Pods()) == 0 {
+		conditions.MarkTrue(machine, DrainingSucceededCondition)
+		return ctrl.Result{}, nil
+	}
+
+	// Make one bounded eviction attempt per reconcile; whatever's still
+	// running afterwards (PDBs blocking eviction, a slow termination, ...)
+	// is left for the next requeued attempt rather than retried here.
+	if err := helper.DeleteOrEvictPods(podDeleteList.Pods()); err != nil {
+		recorder.Eventf(machine, corev1.EventTypeWarning, "NodeDrain", "Eviction attempt on node %s hit an error, will retry: %v", node.Name, err)
+	}
+
+	remaining, errs := helper.GetPodsForDeletion(node.Name)
+	if errs != nil {
+		return ctrl.Result{}, errors.Wrap(errs, "listing remaining pods")
+	}
+	if len(remaining.Pods()) == 0 {
+		conditions.MarkTrue(machine, DrainingSucceededCondition)
+		return ctrl.Result{}, nil
+	}
+
+	conditions.MarkFalse(machine, DrainingSucceededCondition, "DrainInProgress", clusterv1.ConditionSeverityInfo, "%d pod(s) remaining", len(remaining.Pods()))
+	recorder.Eventf(machine, corev1.EventTypeNormal, "NodeDrain", "Drain of node %s still in progress, %d pod(s) remaining", node.Name, len(remaining.Pods()))
+	return ctrl.Result{RequeueAfter: DrainRequeueAfter}, nil
+}
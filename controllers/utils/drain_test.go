@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+func machineWithNodeRef(nodeName string) *infrav1.CloudStackMachine {
+	machine := &infrav1.CloudStackMachine{}
+	machine.Status.NodeRef = &corev1.ObjectReference{Name: nodeName}
+	return machine
+}
+
+func TestDrainNodeNoNodeRef(t *testing.T) {
+	machine := &infrav1.CloudStackMachine{}
+
+	res, err := DrainNode(&ReconciliationRunner{}, machine, nil)
+	require.NoError(t, err)
+	require.True(t, res.IsZero())
+}
+
+func TestDrainNodeNoCAPICluster(t *testing.T) {
+	machine := machineWithNodeRef("node-1")
+
+	_, err := DrainNode(&ReconciliationRunner{}, machine, nil)
+	require.Error(t, err)
+}
+
+func TestDrainNode(t *testing.T) {
+	t.Run("requeues while non-DaemonSet pods remain on the node", func(t *testing.T) {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "blocked-pod", Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "node-1"},
+		}
+		clientset := fake.NewSimpleClientset(node, pod)
+		machine := machineWithNodeRef("node-1")
+
+		res, err := drainNode(context.Background(), clientset, machine, record.NewFakeRecorder(10))
+		require.NoError(t, err)
+		require.Equal(t, DrainRequeueAfter, res.RequeueAfter)
+
+		cond := conditions.Get(machine, DrainingSucceededCondition)
+		require.NotNil(t, cond)
+		require.Equal(t, corev1.ConditionFalse, cond.Status)
+		require.Equal(t, "DrainInProgress", cond.Reason)
+	})
+
+	t.Run("succeeds once no non-DaemonSet pods remain on the node", func(t *testing.T) {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+		clientset := fake.NewSimpleClientset(node)
+		machine := machineWithNodeRef("node-1")
+
+		res, err := drainNode(context.Background(), clientset, machine, record.NewFakeRecorder(10))
+		require.NoError(t, err)
+		require.True(t, res.IsZero())
+
+		cond := conditions.Get(machine, DrainingSucceededCondition)
+		require.NotNil(t, cond)
+		require.Equal(t, corev1.ConditionTrue, cond.Status)
+	})
+
+	t.Run("succeeds when the node is already gone", func(t *testing.T) {
+		clientset := fake.NewSimpleClientset()
+		machine := machineWithNodeRef("node-1")
+
+		res, err := drainNode(context.Background(), clientset, machine, record.NewFakeRecorder(10))
+		require.NoError(t, err)
+		require.True(t, res.IsZero())
+
+		cond := conditions.Get(machine, DrainingSucceededCondition)
+		require.NotNil(t, cond)
+		require.Equal(t, corev1.ConditionTrue, cond.Status)
+	})
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"sigs.k8s.io/cluster-api/util/patch"
+)
+
+// EnforceOwnerReferences makes sure obj carries an ownerReference to every
+// object in owners. The CloudStackCluster reconciler runs it as an early
+// stage against the owning CAPI Cluster, so a flake that strips that owner
+// reference on one reconcile is self-healed on the next, instead of leaking
+// the object once clusterctl move or delete relies on that owner graph. The
+// CloudStackMachine reconciler does not run it. It is a no-op on objects being
+// deleted: adding owner references at that point would only race the
+// garbage collector. Finalizer bookkeeping is handled separately by
+// util/finalizers.EnsureFinalizer (run generically by DispatchNormalOrDelete)
+// rather than here, so there's a single place that owns it.
+func EnforceOwnerReferences(ctx context.Context, c client.Client, obj client.Object, owners ...client.Object) (bool, error) {
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return false, nil
+	}
+
+	changed := false
+	for _, owner := range owners {
+		if !hasOwnerReference(obj, owner) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	patchHelper, err := patch.NewHelper(obj, c)
+	if err != nil {
+		return false, errors.Wrap(err, "creating patch helper")
+	}
+
+	for _, owner := range owners {
+		if !hasOwnerReference(obj, owner) {
+			if err := controllerutil.SetOwnerReference(owner, obj, c.Scheme()); err != nil {
+				return false, errors.Wrapf(err, "setting owner reference to %s/%s", owner.GetObjectKind().GroupVersionKind().Kind, owner.GetName())
+			}
+		}
+	}
+
+	if err := patchHelper.Patch(ctx, obj); err != nil {
+		return false, errors.Wrapf(err, "patching owner references onto %s/%s", obj.GetNamespace(), obj.GetName())
+	}
+	return true, nil
+}
+
+// hasOwnerReference reports whether obj already has an ownerReference
+// matching owner's UID.
+func hasOwnerReference(obj client.Object, owner client.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == owner.GetUID() {
+			return true
+		}
+	}
+	return false
+}
+
+// EnforceOwnerReferencesStage returns a stage that enforces the CloudStack
+// owner-chain invariants for ReconciliationSubject against owners. owners
+// should already be resolved (e.g. by a prior stage) by the time this stage
+// runs.
+func (r *ReconciliationRunner) EnforceOwnerReferencesStage(owners ...client.Object) ReconciliationStage {
+	return func() (ctrl.Result, error) {
+		added, err := EnforceOwnerReferences(r.RequestCtx, r.K8sClient, r.ReconciliationSubject, owners...)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if added {
+			return ctrl.Result{Requeue: true}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+// TestEnforceOwnerReferences below covers the EnforceOwnerReferences helper
+// against a fake client. The end-to-end acceptance scenario from the
+// originating request - create+delete a Cluster and assert the resulting
+// owner graph via clusterctl's GetOwnerGraph shape - is covered separately
+// by the envtest suite in ownerrefs_envtest_test.go.
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func ownerReferenceTo(owner *corev1.ConfigMap) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: corev1.SchemeGroupVersion.String(),
+		Kind:       "ConfigMap",
+		Name:       owner.Name,
+		UID:        owner.UID,
+	}
+}
+
+func TestEnforceOwnerReferences(t *testing.T) {
+	t.Run("adds a missing owner reference", func(t *testing.T) {
+		owner := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default", UID: types.UID("owner-uid")}}
+		subject := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "subject", Namespace: "default"}}
+		c := fake.NewClientBuilder().WithObjects(owner, subject).Build()
+
+		changed, err := EnforceOwnerReferences(context.Background(), c, subject, owner)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.True(t, hasOwnerReference(subject, owner))
+	})
+
+	t.Run("is a no-op once the owner reference already holds", func(t *testing.T) {
+		owner := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default", UID: types.UID("owner-uid")}}
+		subject := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name: "subject", Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{ownerReferenceTo(owner)},
+		}}
+		c := fake.NewClientBuilder().WithObjects(owner, subject).Build()
+
+		changed, err := EnforceOwnerReferences(context.Background(), c, subject, owner)
+		require.NoError(t, err)
+		require.False(t, changed)
+	})
+
+	t.Run("is a no-op on an object being deleted", func(t *testing.T) {
+		owner := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default", UID: types.UID("owner-uid")}}
+		now := metav1.NewTime(time.Now())
+		subject := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name: "subject", Namespace: "default",
+			Finalizers:        []string{"keep-alive-for-test"},
+			DeletionTimestamp: &now,
+		}}
+		c := fake.NewClientBuilder().WithObjects(owner, subject).Build()
+
+		changed, err := EnforceOwnerReferences(context.Background(), c, subject, owner)
+		require.NoError(t, err)
+		require.False(t, changed)
+		require.False(t, hasOwnerReference(subject, owner))
+	})
+}
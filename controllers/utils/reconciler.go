@@ -0,0 +1,243 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package utils provides the shared reconciliation machinery used by every
+// CloudStack* controller: fetching the reconciliation subject, resolving its
+// owning CAPI Cluster, and running an ordered list of reconciliation stages.
+package utils
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-cloudstack/api/v1beta2"
+	"sigs.k8s.io/cluster-api-provider-cloudstack/util/finalizers"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/patch"
+)
+
+// ReconcilerBase holds the dependencies every CloudStack* reconciler needs from
+// the controller manager. It is embedded by the concrete *Reconciler types.
+type ReconcilerBase struct {
+	K8sClient client.Client
+	Log       logr.Logger
+	Recorder  record.EventRecorder
+}
+
+// ReconciliationMethods is implemented by the per-kind reconciliation runner
+// (e.g. CloudStackClusterReconciliationRunner) so the generic runner below
+// can dispatch to it without knowing its concrete type.
+type ReconciliationMethods interface {
+	Reconcile() (ctrl.Result, error)
+	ReconcileDelete() (ctrl.Result, error)
+}
+
+// ReconciliationStage is a single unit of work run by RunReconciliationStages.
+type ReconciliationStage func() (ctrl.Result, error)
+
+// ReconciliationRunner carries the per-request state shared by every
+// CloudStack* reconciliation runner and implements the base reconciliation
+// stages common to all of them (fetch, owner lookup, dispatch). None of these
+// stages checks whether the owning Cluster is paused.
+type ReconciliationRunner struct {
+	ReconcilerBase
+	ReconciliationSubject client.Object
+	CAPICluster           *clusterv1.Cluster
+	CSCluster             *infrav1.CloudStackCluster
+	RequestCtx            context.Context
+	ReconcilerName        string
+	// Finalizer is added to ReconciliationSubject before any other
+	// reconciliation stage runs, so a delete landing mid-reconcile never
+	// loses the ability to clean up child resources. Set by the concrete
+	// runner's constructor.
+	Finalizer string
+	// PatchHelper persists ReconciliationSubject (including its status
+	// subresource) once reconciliation is done. Set by SetupPatchHelper.
+	PatchHelper *patch.Helper
+
+	req             ctrl.Request
+	reconcileNormal func() (ctrl.Result, error)
+	reconcileDelete func() (ctrl.Result, error)
+}
+
+// NewRunner links a concrete reconciliation runner (owner) to the generic
+// base runner so that DispatchNormalOrDelete can dispatch to owner's
+// Reconcile/ReconcileDelete without a type switch.
+func NewRunner(owner ReconciliationMethods, subject client.Object, name string) *ReconciliationRunner {
+	return &ReconciliationRunner{
+		ReconciliationSubject: subject,
+		ReconcilerName:        name,
+		reconcileNormal:       owner.Reconcile,
+		reconcileDelete:       owner.ReconcileDelete,
+	}
+}
+
+// UsingBaseReconciler supplies the manager-provided dependencies for this request.
+func (r *ReconciliationRunner) UsingBaseReconciler(base ReconcilerBase) *ReconciliationRunner {
+	r.ReconcilerBase = base
+	return r
+}
+
+// ForRequest records the controller-runtime request being served.
+func (r *ReconciliationRunner) ForRequest(req ctrl.Request) *ReconciliationRunner {
+	r.req = req
+	return r
+}
+
+// WithRequestCtx records the context for this request.
+func (r *ReconciliationRunner) WithRequestCtx(ctx context.Context) *ReconciliationRunner {
+	r.RequestCtx = ctx
+	return r
+}
+
+// GetReconciliationSubject fetches ReconciliationSubject by the request's
+// NamespacedName. Controllers call it first in their Reconcile method.
+// found is false, with a nil error, when the object is gone (already deleted
+// and finalized); callers must check found themselves and return early.
+func (r *ReconciliationRunner) GetReconciliationSubject() (found bool, err error) {
+	if err := r.K8sClient.Get(r.RequestCtx, r.req.NamespacedName, r.ReconciliationSubject); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "getting reconciliation subject")
+	}
+	return true, nil
+}
+
+// SetupPatchHelper creates the patch helper used to persist
+// ReconciliationSubject, including its status subresource, once
+// reconciliation completes. Callers defer PersistReconciliationSubject
+// immediately after calling this so status is saved on every return path,
+// including early returns and errors.
+func (r *ReconciliationRunner) SetupPatchHelper() error {
+	patchHelper, err := patch.NewHelper(r.ReconciliationSubject, r.K8sClient)
+	if err != nil {
+		return errors.Wrap(err, "creating patch helper")
+	}
+	r.PatchHelper = patchHelper
+	return nil
+}
+
+// PersistReconciliationSubject patches ReconciliationSubject via PatchHelper.
+// It is meant to run in a defer in the controller's Reconcile method so the
+// object (and its status) is saved regardless of which stage returned.
+func (r *ReconciliationRunner) PersistReconciliationSubject() error {
+	return errors.Wrap(r.PatchHelper.Patch(r.RequestCtx, r.ReconciliationSubject), "patching reconciliation subject")
+}
+
+// DispatchNormalOrDelete ensures the finalizer is present (requeueing once if
+// it had to be added) and then runs either reconcileNormal or
+// reconcileDelete, purely based on whether ReconciliationSubject is being
+// deleted.
+func (r *ReconciliationRunner) DispatchNormalOrDelete() (ctrl.Result, error) {
+	if r.ReconciliationSubject.GetDeletionTimestamp().IsZero() {
+		if r.Finalizer != "" {
+			added, err := finalizers.EnsureFinalizer(r.RequestCtx, r.K8sClient, r.ReconciliationSubject, r.Finalizer)
+			if err != nil {
+				return ctrl.Result{}, errors.Wrap(err, "ensuring finalizer")
+			}
+			if added {
+				// The patch above already persisted the finalizer; requeue so
+				// the rest of reconciliation sees a fresh copy of the object.
+				return ctrl.Result{Requeue: true}, nil
+			}
+		}
+		return r.reconcileNormal()
+	}
+	return r.reconcileDelete()
+}
+
+// RunReconciliationStages runs each stage in order, stopping and returning as
+// soon as one produces a requeue, an error, or a non-empty result.
+func (r *ReconciliationRunner) RunReconciliationStages(stages ...ReconciliationStage) (ctrl.Result, error) {
+	for _, stage := range stages {
+		if res, err := stage(); r.ShouldReturn(res, err) {
+			return res, err
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+// ShouldReturn reports whether a stage's result should short-circuit the
+// remaining reconciliation stages.
+func (r *ReconciliationRunner) ShouldReturn(res ctrl.Result, err error) bool {
+	return err != nil || res.Requeue || res.RequeueAfter > 0
+}
+
+// RequeueWithMessage logs msg and requeues the current request.
+func (r *ReconciliationRunner) RequeueWithMessage(msg string, keysAndValues ...interface{}) (ctrl.Result, error) {
+	r.Log.Info(msg, keysAndValues...)
+	return ctrl.Result{Requeue: true}, nil
+}
+
+// GetCAPICluster resolves the CAPI Cluster owning ReconciliationSubject via
+// its cluster.x-k8s.io/cluster-name label and stores it in r.CAPICluster.
+func (r *ReconciliationRunner) GetCAPICluster() (ctrl.Result, error) {
+	clusterName, ok := r.ReconciliationSubject.GetLabels()[clusterv1.ClusterNameLabel]
+	if !ok {
+		return r.RequeueWithMessage("ReconciliationSubject has no cluster name label yet, requeueing.")
+	}
+
+	cluster := &clusterv1.Cluster{}
+	key := client.ObjectKey{Namespace: r.ReconciliationSubject.GetNamespace(), Name: clusterName}
+	if err := r.K8sClient.Get(r.RequestCtx, key, cluster); err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "getting owning CAPI Cluster")
+	}
+	r.CAPICluster = cluster
+	return ctrl.Result{}, nil
+}
+
+// GetFailureDomains returns a stage that lists every CloudStackFailureDomain
+// in the reconciliation subject's namespace into dest. It does not filter by
+// owning CAPI Cluster or CloudStackCluster.
+func (r *ReconciliationRunner) GetFailureDomains(dest *infrav1.CloudStackFailureDomainList) ReconciliationStage {
+	return func() (ctrl.Result, error) {
+		if err := r.K8sClient.List(r.RequestCtx, dest, client.InNamespace(r.ReconciliationSubject.GetNamespace())); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "listing FailureDomains")
+		}
+		return ctrl.Result{}, nil
+	}
+}
+
+// CreateFailureDomains returns a stage that ensures a CloudStackFailureDomain
+// exists for each entry in specs, owned by r.CSCluster. Setting the owner
+// reference here, rather than relying on a later EnforceOwnerReferences
+// stage to self-heal it, closes the window where a FailureDomain could
+// otherwise exist without one even momentarily.
+func (r *ReconciliationRunner) CreateFailureDomains(specs []infrav1.CloudStackFailureDomainSpec) ReconciliationStage {
+	return func() (ctrl.Result, error) {
+		for _, spec := range specs {
+			fd := &infrav1.CloudStackFailureDomain{}
+			fd.Name = spec.Name
+			fd.Namespace = r.ReconciliationSubject.GetNamespace()
+			fd.Spec = spec
+			if _, err := ctrl.CreateOrUpdate(r.RequestCtx, r.K8sClient, fd, func() error {
+				fd.Spec = spec
+				return controllerutil.SetOwnerReference(r.CSCluster, fd, r.K8sClient.Scheme())
+			}); err != nil {
+				return ctrl.Result{}, errors.Wrapf(err, "creating FailureDomain %s", spec.Name)
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package finalizers provides a small helper for adding a finalizer to an
+// object as its own reconciliation stage, so callers can requeue immediately
+// after the finalizer is persisted rather than continuing to act on an object
+// that isn't yet safe to clean up.
+package finalizers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	ctrlutilpatch "sigs.k8s.io/cluster-api/util/patch"
+)
+
+// EnsureFinalizer adds finalizer to obj and patches it if it isn't already
+// present. It returns (true, nil) when it had to add and patch the finalizer,
+// so the caller can stop reconciling and requeue rather than proceed to act on
+// an object that wasn't yet guaranteed to be cleaned up. It is a no-op, both
+// on objects already carrying the finalizer and on objects being deleted.
+func EnsureFinalizer(ctx context.Context, c client.Client, obj client.Object, finalizer string) (bool, error) {
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return false, nil
+	}
+	if controllerutil.ContainsFinalizer(obj, finalizer) {
+		return false, nil
+	}
+
+	patchHelper, err := ctrlutilpatch.NewHelper(obj, c)
+	if err != nil {
+		return false, errors.Wrap(err, "creating patch helper")
+	}
+
+	controllerutil.AddFinalizer(obj, finalizer)
+
+	if err := patchHelper.Patch(ctx, obj); err != nil {
+		return false, errors.Wrapf(err, "patching finalizer %q onto %s/%s", finalizer, obj.GetNamespace(), obj.GetName())
+	}
+	return true, nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2022 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package finalizers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testFinalizer = "test.cluster.x-k8s.io"
+
+func newSecret(opts ...func(*corev1.Secret)) *corev1.Secret {
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "subject", Namespace: "default"}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func TestEnsureFinalizer(t *testing.T) {
+	t.Run("adds the finalizer and reports it was added", func(t *testing.T) {
+		obj := newSecret()
+		c := fake.NewClientBuilder().WithObjects(obj).Build()
+
+		added, err := EnsureFinalizer(context.Background(), c, obj, testFinalizer)
+		require.NoError(t, err)
+		require.True(t, added)
+		require.Contains(t, obj.Finalizers, testFinalizer)
+	})
+
+	t.Run("is a no-op when the finalizer is already present", func(t *testing.T) {
+		obj := newSecret(func(s *corev1.Secret) { s.Finalizers = []string{testFinalizer} })
+		c := fake.NewClientBuilder().WithObjects(obj).Build()
+
+		added, err := EnsureFinalizer(context.Background(), c, obj, testFinalizer)
+		require.NoError(t, err)
+		require.False(t, added)
+	})
+
+	t.Run("is a no-op on an object being deleted", func(t *testing.T) {
+		now := metav1.NewTime(time.Now())
+		obj := newSecret(func(s *corev1.Secret) {
+			s.Finalizers = []string{"keep-alive-for-test"}
+			s.DeletionTimestamp = &now
+		})
+		c := fake.NewClientBuilder().WithObjects(obj).Build()
+
+		added, err := EnsureFinalizer(context.Background(), c, obj, testFinalizer)
+		require.NoError(t, err)
+		require.False(t, added)
+		require.NotContains(t, obj.Finalizers, testFinalizer)
+	})
+}